@@ -0,0 +1,345 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package codewalk parses and renders codewalks: narrated, multi-file
+// tours through a module's source, modeled on the codewalk feature of the
+// original godoc (see golang.org/x/tools/cmd/godoc's codewalk.go). A
+// codewalk is an XML document listing ordered steps, each pointing at a
+// file and an optional line range to display alongside a comment. Render
+// produces HTML, analogous to dochtml.Render, that shows each step's
+// commentary followed by a line-anchored view of the step's file. The code
+// is not syntax-highlighted.
+//
+// TODO: this package only renders codewalks that are handed to it; no
+// caller yet discovers *.codewalk files at a package's root and passes
+// them in. See dochtml.RenderOptions.Codewalks.
+package codewalk
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"go/token"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// A Walk is a parsed codewalk descriptor: an ordered tour through a
+// module's source.
+type Walk struct {
+	XMLName xml.Name `xml:"codewalk"`
+	Title   string   `xml:"title,attr"`
+	Steps   []*Step  `xml:"step"`
+}
+
+// A Step is a single stop in a Walk: a file, an optional line range within
+// it, and commentary describing what the reader should look at.
+type Step struct {
+	// Title is a short heading for the step, shown in the step list.
+	Title string `xml:"title,attr"`
+
+	// Src is "file" or "file:low,high", where low and high are 1-based,
+	// inclusive line numbers bounding the region of file to display. The
+	// original godoc codewalk format also allows src="file:/re/,/re/";
+	// that form is not supported here.
+	Src string `xml:"src,attr"`
+
+	// Comment is the step's commentary, as a fragment of XHTML.
+	Comment InnerXML `xml:"xml"`
+
+	// File and Lines are populated from Src by Parse.
+	File  string
+	Lines LineRange
+}
+
+// InnerXML holds a step's <xml> commentary payload verbatim.
+//
+// A codewalk descriptor's commentary comes from the module's own source
+// tree, the same as any other file Render reads, so it is untrusted
+// content in the same way a doc comment or README is: it is rendered to
+// every visitor of the page, but it is not reviewed or escaped by its
+// author with an HTML audience in mind. HTML renders Content's markup
+// through sanitizeXHTML rather than embedding it verbatim, to keep basic
+// formatting (paragraphs, emphasis, links) while dropping anything that
+// could inject script or attributes outside that allow-list.
+type InnerXML struct {
+	Content string `xml:",innerxml"`
+}
+
+// HTML returns x's commentary as sanitized HTML, safe to embed in a
+// rendered page. See InnerXML.
+func (x InnerXML) HTML() template.HTML {
+	return sanitizeXHTML(x.Content)
+}
+
+// commentAllowedTags is the set of element names sanitizeXHTML preserves
+// in codewalk commentary. It covers the basic prose markup a codewalk
+// author needs and nothing that can execute script or navigate outside
+// an explicit href.
+var commentAllowedTags = map[string]bool{
+	"p": true, "br": true, "em": true, "strong": true,
+	"code": true, "pre": true, "ul": true, "ol": true, "li": true,
+	"a": true,
+}
+
+// sanitizeXHTML re-renders the XHTML fragment raw, keeping only the tags
+// in commentAllowedTags (and, for "a", only its href attribute, and only
+// if href has an http, https, or relative scheme). Any other element is
+// dropped but its text content is kept; all text is HTML-escaped. This
+// lets a codewalk's <xml> commentary, which comes from the module's own
+// source tree and is not reviewed with an HTML audience in mind, use
+// basic formatting without being able to inject script or markup outside
+// that allow-list.
+func sanitizeXHTML(raw string) template.HTML {
+	// root wraps raw in a synthetic root element so multiple
+	// top-level elements (or bare text) parse as a single well-formed
+	// document; it is never itself written to buf.
+	const root = "sanitizexhtmlroot"
+	dec := xml.NewDecoder(strings.NewReader("<" + root + ">" + raw + "</" + root + ">"))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	var buf bytes.Buffer
+	depth := 0 // depth of disallowed elements we're currently inside
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == root {
+				continue
+			}
+			if !commentAllowedTags[t.Name.Local] {
+				depth++
+				continue
+			}
+			if depth > 0 {
+				continue
+			}
+			if t.Name.Local == "a" {
+				href := safeHref(t)
+				if href == "" {
+					buf.WriteString("<a>")
+				} else {
+					fmt.Fprintf(&buf, `<a href="%s">`, template.HTMLEscapeString(href))
+				}
+				continue
+			}
+			fmt.Fprintf(&buf, "<%s>", t.Name.Local)
+		case xml.EndElement:
+			if t.Name.Local == root {
+				continue
+			}
+			if !commentAllowedTags[t.Name.Local] {
+				if depth > 0 {
+					depth--
+				}
+				continue
+			}
+			if depth > 0 {
+				continue
+			}
+			fmt.Fprintf(&buf, "</%s>", t.Name.Local)
+		case xml.CharData:
+			// Text survives even inside a dropped element (e.g. a
+			// stray <div> or <span>), matching the doc comment above:
+			// only the disallowed tag itself is dropped, not its
+			// content. It's still escaped, so this can't smuggle
+			// markup back in.
+			template.HTMLEscape(&buf, t)
+		}
+	}
+	return template.HTML(buf.String())
+}
+
+// safeHref returns the value of a's href attribute, or "" if a has none
+// or its href uses a scheme other than http, https, or a relative path
+// (e.g. it rejects "javascript:" as well as protocol-relative URLs like
+// "//evil.example/phish", which have no scheme but would otherwise slip
+// through as "relative").
+func safeHref(a xml.StartElement) string {
+	for _, attr := range a.Attr {
+		if attr.Name.Local != "href" {
+			continue
+		}
+		href := attr.Value
+		if strings.HasPrefix(href, "//") {
+			return ""
+		}
+		if i := strings.IndexByte(href, ':'); i >= 0 {
+			switch strings.ToLower(href[:i]) {
+			case "http", "https":
+				return href
+			default:
+				return ""
+			}
+		}
+		return href
+	}
+	return ""
+}
+
+// A LineRange is a 1-based, inclusive range of lines within a file. A
+// zero Low and High means the whole file.
+type LineRange struct {
+	Low, High int
+}
+
+// Parse parses a codewalk descriptor in the format described by Walk.
+func Parse(data []byte) (*Walk, error) {
+	var w Walk
+	if err := xml.Unmarshal(data, &w); err != nil {
+		return nil, fmt.Errorf("codewalk: parsing descriptor: %v", err)
+	}
+	for i, s := range w.Steps {
+		file, lines, err := parseSrc(s.Src)
+		if err != nil {
+			return nil, fmt.Errorf("codewalk: step %d (%q): %v", i, s.Title, err)
+		}
+		s.File = file
+		s.Lines = lines
+	}
+	return &w, nil
+}
+
+// parseSrc splits a step's src attribute into a file path and line range.
+func parseSrc(src string) (file string, lines LineRange, err error) {
+	i := strings.LastIndexByte(src, ':')
+	if i < 0 {
+		return src, LineRange{}, nil
+	}
+	file, rng := src[:i], src[i+1:]
+	low := rng
+	high := rng
+	hasHigh := false
+	if j := strings.IndexByte(rng, ','); j >= 0 {
+		low, high, hasHigh = rng[:j], rng[j+1:], true
+	}
+	lowN, err := strconv.Atoi(low)
+	if err != nil {
+		// Not a "file:low,high" form (e.g. a Windows path); treat the
+		// whole src as a file name.
+		return src, LineRange{}, nil
+	}
+	if !hasHigh {
+		return file, LineRange{Low: lowN, High: lowN}, nil
+	}
+	highN, err := strconv.Atoi(high)
+	if err != nil {
+		return "", LineRange{}, fmt.Errorf("invalid line range %q", rng)
+	}
+	return file, LineRange{Low: lowN, High: highN}, nil
+}
+
+// Options configures Render.
+type Options struct {
+	// ReadFile reads the contents of the file at path, resolved against
+	// the module's file system. It is required.
+	ReadFile func(path string) ([]byte, error)
+
+	// SourceLinkFunc, if set, returns a link to the given file and
+	// 1-based line number in the module's source view.
+	SourceLinkFunc func(file string, line int) string
+}
+
+// Render renders w as an HTML fragment, reading each step's file via
+// opt.ReadFile. Steps are rendered one after another, each with its
+// title, commentary, file name, and a line-anchored code block of that
+// file; there is no separate step list beside a single code frame, and
+// nothing keeps the two in sync. fset is used to register each resolved
+// file so its positions can be shared with dochtml's rendering pipeline.
+func Render(fset *token.FileSet, w *Walk, opt Options) ([]byte, error) {
+	if opt.ReadFile == nil {
+		return nil, fmt.Errorf("codewalk: Render: Options.ReadFile is required")
+	}
+
+	type renderedStep struct {
+		*Step
+		ID   string
+		Code template.HTML
+	}
+	steps := make([]*renderedStep, len(w.Steps))
+	for i, s := range w.Steps {
+		src, err := opt.ReadFile(s.File)
+		if err != nil {
+			return nil, fmt.Errorf("codewalk: reading %s: %v", s.File, err)
+		}
+		fset.AddFile(s.File, -1, len(src))
+		steps[i] = &renderedStep{
+			Step: s,
+			ID:   fmt.Sprintf("step-%d", i+1),
+			Code: codeHTML(s.File, src, s.Lines, opt.SourceLinkFunc),
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := walkTemplate.Execute(&buf, struct {
+		*Walk
+		Steps interface{}
+	}{
+		Walk:  w,
+		Steps: steps,
+	}); err != nil {
+		return nil, fmt.Errorf("codewalk: Render: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// codeHTML renders src as an HTML <pre> block with one
+// <span id="<file>-L123"> per line (file with "/" and "." replaced by "-",
+// see fileAnchor), so individual lines can be linked and highlighted.
+// Lines outside lines (if non-zero) also get the "codewalk-line-dim" CSS
+// class.
+func codeHTML(file string, src []byte, lines LineRange, sourceLink func(string, int) string) template.HTML {
+	all := strings.Split(strings.TrimRight(string(src), "\n"), "\n")
+	var buf bytes.Buffer
+	buf.WriteString(`<pre class="codewalk-code">`)
+	for i, line := range all {
+		n := i + 1
+		class := "codewalk-line"
+		if lines.Low != 0 && (n < lines.Low || n > lines.High) {
+			class += " codewalk-line-dim"
+		}
+		fmt.Fprintf(&buf, `<span id="%s-L%d" class="%s">`, fileAnchor(file), n, class)
+		linked := false
+		if sourceLink != nil {
+			if href := sourceLink(file, n); href != "" {
+				fmt.Fprintf(&buf, `<a href="%s">`, template.HTMLEscapeString(href))
+				linked = true
+			}
+		}
+		template.HTMLEscape(&buf, []byte(line))
+		if linked {
+			buf.WriteString("</a>")
+		}
+		buf.WriteString("</span>\n")
+	}
+	buf.WriteString(`</pre>`)
+	return template.HTML(buf.String())
+}
+
+func fileAnchor(file string) string {
+	return strings.NewReplacer("/", "-", ".", "-").Replace(file)
+}
+
+// walkTemplate is the template used to render a Walk.
+var walkTemplate = template.Must(template.New("codewalk").Parse(`{{- "" -}}
+<div class="codewalk">
+	<h1>{{.Title}}</h1>
+	<div class="codewalk-steps">
+	{{range .Steps}}
+		<div class="codewalk-step" id="{{.ID}}">
+			<h3>{{.Title}}</h3>
+			<div class="codewalk-comment">{{.Comment.HTML}}</div>
+			<div class="codewalk-file">{{.File}}</div>
+			{{.Code}}
+		</div>
+	{{end}}
+	</div>
+</div>
+`))
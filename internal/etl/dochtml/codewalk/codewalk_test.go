@@ -0,0 +1,116 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codewalk
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeXHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "allowed tags pass through",
+			raw:  `<p>Hello <em>world</em>.</p>`,
+			want: `<p>Hello <em>world</em>.</p>`,
+		},
+		{
+			name: "disallowed tag dropped, text kept",
+			raw:  `<p>before <div>inside</div> after</p>`,
+			want: `<p>before inside after</p>`,
+		},
+		{
+			name: "nested disallowed tags collapse to their text",
+			raw:  `<p>a <div>b <span>c</span> d</div> e</p>`,
+			want: `<p>a b c d e</p>`,
+		},
+		{
+			name: "text is escaped",
+			raw:  `<p>a &lt; b &amp; c</p>`,
+			want: `<p>a &lt; b &amp; c</p>`,
+		},
+		{
+			name: "script tag dropped, its content kept as text",
+			raw:  `<p>safe</p><script>alert(1)</script>`,
+			want: `<p>safe</p>alert(1)`,
+		},
+		{
+			name: "a with http href kept",
+			raw:  `<a href="http://example.com">link</a>`,
+			want: `<a href="http://example.com">link</a>`,
+		},
+		{
+			name: "a with https href kept",
+			raw:  `<a href="https://example.com">link</a>`,
+			want: `<a href="https://example.com">link</a>`,
+		},
+		{
+			name: "a with relative href kept",
+			raw:  `<a href="/doc/foo">link</a>`,
+			want: `<a href="/doc/foo">link</a>`,
+		},
+		{
+			name: "a with javascript href has href dropped",
+			raw:  `<a href="javascript:alert(1)">link</a>`,
+			want: `<a>link</a>`,
+		},
+		{
+			name: "a with protocol-relative href has href dropped",
+			raw:  `<a href="//evil.example/phish">link</a>`,
+			want: `<a>link</a>`,
+		},
+		{
+			name: "a with disallowed attribute keeps only href",
+			raw:  `<a href="/x" onclick="alert(1)">link</a>`,
+			want: `<a href="/x">link</a>`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := string(sanitizeXHTML(tc.raw))
+			if got != tc.want {
+				t.Errorf("sanitizeXHTML(%q) = %q, want %q", tc.raw, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSafeHref(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want string
+	}{
+		{name: "http scheme allowed", tag: `<a href="http://example.com">`, want: "http://example.com"},
+		{name: "https scheme allowed", tag: `<a href="https://example.com/x">`, want: "https://example.com/x"},
+		{name: "relative path allowed", tag: `<a href="/doc/foo">`, want: "/doc/foo"},
+		{name: "bare relative path allowed", tag: `<a href="foo.html">`, want: "foo.html"},
+		{name: "javascript scheme rejected", tag: `<a href="javascript:alert(1)">`, want: ""},
+		{name: "mailto scheme rejected", tag: `<a href="mailto:a@example.com">`, want: ""},
+		{name: "protocol-relative rejected", tag: `<a href="//evil.example/phish">`, want: ""},
+		{name: "no href", tag: `<a>`, want: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := xml.NewDecoder(strings.NewReader(tc.tag + "</a>"))
+			tok, err := dec.Token()
+			if err != nil {
+				t.Fatalf("decoding token: %v", err)
+			}
+			start, ok := tok.(xml.StartElement)
+			if !ok {
+				t.Fatalf("token is %T, not xml.StartElement", tok)
+			}
+			if got := safeHref(start); got != tc.want {
+				t.Errorf("safeHref(%s) = %q, want %q", tc.tag, got, tc.want)
+			}
+		})
+	}
+}
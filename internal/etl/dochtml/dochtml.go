@@ -2,7 +2,10 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package dochtml renders Go package documentation into HTML.
+// Package dochtml renders Go package documentation into HTML, plain text,
+// or Markdown. All three formats are produced from the same collected
+// Page, via NewRenderer and its RenderHTML/RenderText/RenderMarkdown
+// methods; Render is a convenience wrapper around RenderHTML.
 //
 // This package and its API are under development (see b/137567588).
 // It currently relies on copies of external packages with active CLs applied.
@@ -16,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/printer"
 	"go/token"
 	"html/template"
@@ -38,14 +42,92 @@ var (
 type RenderOptions struct {
 	SourceLinkFunc func(ast.Node) string
 	Limit          int64 // If zero, a default limit of 10 megabytes is used.
+
+	// PlayURLFunc, if set, enables rendering of playground-backed example
+	// code. It is called with the example whose Play field is non-nil and
+	// should return the base URL of a Go playground-compatible backend
+	// (one that serves /compile, /fmt, and /share as the playground does).
+	// The returned URL is emitted as the data-play-url attribute of the
+	// example's playground container, which static/playground.js uses as
+	// the base URL for its Run, Format, and Share requests; if it returns
+	// the empty string, the example is rendered without playground
+	// controls.
+	PlayURLFunc func(*doc.Example) string
+
+	// Codewalks lists the codewalks discovered for this package, if any.
+	// They are linked from the package doc page's index, adjacent to
+	// Examples. Discovering codewalk files (e.g. *.codewalk.xml at the
+	// package root) and populating this slice is the caller's
+	// responsibility; the ETL fetch pipeline does not yet do so.
+	Codewalks []Codewalk
+
+	// TemplateSet, if non-nil, overrides one or more of the named blocks
+	// of the default HTML template (see blockNames) without forking this
+	// package. A nil TemplateSet preserves the current embedded
+	// behavior.
+	TemplateSet *TemplateSet
+
+	// TemplateData is optional site-specific data made available to
+	// override templates as {{.Data}} (see Page.Data), so a custom func
+	// such as a per-site ExampleSuffix can be implemented as a method on
+	// an operator-defined type instead of a global template func. Only
+	// templates executed with the Page as dot (the top-level template and
+	// the "overview", "index", and "codewalks" blocks) can reach it; the
+	// other blocks are passed their own section's values.
+	TemplateData interface{}
 }
 
-// Render renders package documentation HTML for the
-// provided file set and package.
-//
-// If the rendered documentation HTML size exceeds the specified limit,
-// an error with ErrTooLarge in its chain will be returned.
-func Render(fset *token.FileSet, p *doc.Package, opt RenderOptions) ([]byte, error) {
+// Codewalk is a reference to a codewalk rendered by the codewalk package,
+// linked from the package documentation index.
+type Codewalk struct {
+	Title string
+	URL   string
+}
+
+// Page is the format-agnostic data model collected from a parsed package.
+// It is built once by NewRenderer and fed to the HTML, text, and Markdown
+// templates in turn, so all three output formats describe the exact same
+// package, examples, and notes.
+type Page struct {
+	RootURL string
+	*doc.Package
+	Examples  *examples
+	Codewalks []Codewalk
+
+	// Data is the value of RenderOptions.TemplateData, if any. Override
+	// templates that are executed with the Page as dot can reach it as
+	// {{.Data}}.
+	Data interface{}
+}
+
+// Page returns p itself, so an override template can write {{.Page.Foo}}
+// for any of the fields above alongside {{.Data}} for the field of that
+// name.
+func (p *Page) Page() *Page { return p }
+
+// Renderer renders the documentation of a single package, collected once
+// into a Page, into any of several supported output formats.
+type Renderer interface {
+	// RenderHTML renders package documentation as HTML.
+	RenderHTML() ([]byte, error)
+	// RenderText renders package documentation as plain text, in the
+	// style of `go doc`.
+	RenderText() ([]byte, error)
+	// RenderMarkdown renders package documentation as CommonMark.
+	RenderMarkdown() ([]byte, error)
+}
+
+// docRenderer is the Renderer implementation returned by NewRenderer.
+type docRenderer struct {
+	fset *token.FileSet
+	opt  RenderOptions
+	page *Page
+}
+
+// NewRenderer collects p into a Page and returns a Renderer that can emit
+// it as HTML, plain text, or Markdown. fset and opt are interpreted the
+// same way as for Render.
+func NewRenderer(fset *token.FileSet, p *doc.Package, opt RenderOptions) Renderer {
 	if opt.Limit == 0 {
 		const megabyte = 1000 * 1000
 		opt.Limit = 10 * megabyte
@@ -66,7 +148,30 @@ func Render(fset *token.FileSet, p *doc.Package, opt RenderOptions) ([]byte, err
 		p.Examples = nil
 	}
 
-	r := render.New(fset, p, &render.Options{
+	return &docRenderer{
+		fset: fset,
+		opt:  opt,
+		page: &Page{
+			RootURL:   "/pkg",
+			Package:   p,
+			Examples:  collectExamples(fset, p, opt.PlayURLFunc),
+			Codewalks: opt.Codewalks,
+			Data:      opt.TemplateData,
+		},
+	}
+}
+
+// Render renders package documentation HTML for the provided file set and
+// package. It is equivalent to NewRenderer(fset, p, opt).RenderHTML().
+//
+// If the rendered documentation HTML size exceeds the specified limit,
+// an error with ErrTooLarge in its chain will be returned.
+func Render(fset *token.FileSet, p *doc.Package, opt RenderOptions) ([]byte, error) {
+	return NewRenderer(fset, p, opt).RenderHTML()
+}
+
+func (d *docRenderer) RenderHTML() ([]byte, error) {
+	r := render.New(d.fset, d.page.Package, &render.Options{
 		PackageURL: func(path string) (url string) {
 			return pathpkg.Join("/pkg", path)
 		},
@@ -74,7 +179,7 @@ func Render(fset *token.FileSet, p *doc.Package, opt RenderOptions) ([]byte, err
 	})
 
 	sourceLink := func(name string, node ast.Node) template.HTML {
-		link := opt.SourceLinkFunc(node)
+		link := d.opt.SourceLinkFunc(node)
 		if link == "" {
 			return template.HTML(name)
 		}
@@ -83,23 +188,20 @@ func Render(fset *token.FileSet, p *doc.Package, opt RenderOptions) ([]byte, err
 
 	buf := &limitBuffer{
 		B:      new(bytes.Buffer),
-		Remain: opt.Limit,
+		Remain: d.opt.Limit,
 	}
-	err := template.Must(htmlPackage.Clone()).Funcs(map[string]interface{}{
+	t := template.Must(htmlPackage.Clone()).Funcs(map[string]interface{}{
 		"render_synopsis": r.Synopsis,
 		"render_doc":      r.DocHTML,
 		"render_decl":     r.DeclHTML,
 		"render_code":     r.CodeHTML,
 		"source_link":     sourceLink,
-	}).Execute(buf, struct {
-		RootURL string
-		*doc.Package
-		Examples *examples
-	}{
-		RootURL:  "/pkg",
-		Package:  p,
-		Examples: collectExamples(p),
 	})
+	t, err := d.opt.TemplateSet.apply(t)
+	if err != nil {
+		return nil, fmt.Errorf("dochtml.Render: %v", err)
+	}
+	err = t.Execute(buf, d.page)
 	if buf.Remain < 0 {
 		return nil, xerrors.Errorf("dochtml.Render: %w", ErrTooLarge)
 	} else if err != nil {
@@ -120,6 +222,13 @@ type example struct {
 	ID       string // ID of example
 	ParentID string // ID of top-level declaration this example is attached to
 	Suffix   string // optional suffix name
+	PlayURL  string // base URL of the playground backend, or "" if not playable
+	PlaySrc  string // gofmt'd source of Example.Play, or "" if Example.Play is nil
+}
+
+// Playable reports whether ex can be run on the Go playground.
+func (ex *example) Playable() bool {
+	return ex.PlayURL != "" && ex.PlaySrc != ""
 }
 
 // Code returns an printer.CommentedNode if ex.Comments is non-nil,
@@ -133,72 +242,43 @@ func (ex *example) Code() interface{} {
 
 // collectExamples extracts examples from p
 // into the internal examples representation.
-func collectExamples(p *doc.Package) *examples {
+//
+// If playURLFunc is non-nil, each example with a non-nil Play AST is
+// formatted via format.Node so it can be submitted to the playground
+// backend as-is, and playURLFunc is consulted for that example to
+// determine the backend URL. Examples without a Play AST, or whose Play
+// AST fails to format, are not passed to playURLFunc.
+func collectExamples(fset *token.FileSet, p *doc.Package, playURLFunc func(*doc.Example) string) *examples {
 	// TODO(dmitshur): Simplify this further.
 	exs := &examples{
 		List: nil,
 		Map:  make(map[string][]*example),
 	}
+	add := func(id string, ex *doc.Example) {
+		e := newExample(fset, playURLFunc, id, ex)
+		exs.List = append(exs.List, e)
+		exs.Map[id] = append(exs.Map[id], e)
+	}
 	for _, ex := range p.Examples {
-		id := ""
-		ex := &example{
-			Example:  ex,
-			ID:       exampleID(id, ex.Suffix),
-			ParentID: id,
-			Suffix:   ex.Suffix,
-		}
-		exs.List = append(exs.List, ex)
-		exs.Map[id] = append(exs.Map[id], ex)
+		add("", ex)
 	}
 	for _, f := range p.Funcs {
 		for _, ex := range f.Examples {
-			id := f.Name
-			ex := &example{
-				Example:  ex,
-				ID:       exampleID(id, ex.Suffix),
-				ParentID: id,
-				Suffix:   ex.Suffix,
-			}
-			exs.List = append(exs.List, ex)
-			exs.Map[id] = append(exs.Map[id], ex)
+			add(f.Name, ex)
 		}
 	}
 	for _, t := range p.Types {
 		for _, ex := range t.Examples {
-			id := t.Name
-			ex := &example{
-				Example:  ex,
-				ID:       exampleID(id, ex.Suffix),
-				ParentID: id,
-				Suffix:   ex.Suffix,
-			}
-			exs.List = append(exs.List, ex)
-			exs.Map[id] = append(exs.Map[id], ex)
+			add(t.Name, ex)
 		}
 		for _, f := range t.Funcs {
 			for _, ex := range f.Examples {
-				id := f.Name
-				ex := &example{
-					Example:  ex,
-					ID:       exampleID(id, ex.Suffix),
-					ParentID: id,
-					Suffix:   ex.Suffix,
-				}
-				exs.List = append(exs.List, ex)
-				exs.Map[id] = append(exs.Map[id], ex)
+				add(f.Name, ex)
 			}
 		}
 		for _, m := range t.Methods {
 			for _, ex := range m.Examples {
-				id := t.Name + "." + m.Name
-				ex := &example{
-					Example:  ex,
-					ID:       exampleID(id, ex.Suffix),
-					ParentID: id,
-					Suffix:   ex.Suffix,
-				}
-				exs.List = append(exs.List, ex)
-				exs.Map[id] = append(exs.Map[id], ex)
+				add(t.Name+"."+m.Name, ex)
 			}
 		}
 	}
@@ -210,6 +290,27 @@ func collectExamples(p *doc.Package) *examples {
 	return exs
 }
 
+// newExample builds the internal representation of a single doc.Example
+// attached to the top-level declaration named id ("" for package examples).
+func newExample(fset *token.FileSet, playURLFunc func(*doc.Example) string, id string, ex *doc.Example) *example {
+	e := &example{
+		Example:  ex,
+		ID:       exampleID(id, ex.Suffix),
+		ParentID: id,
+		Suffix:   ex.Suffix,
+	}
+	if playURLFunc == nil || ex.Play == nil {
+		return e
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, ex.Play); err != nil {
+		return e
+	}
+	e.PlayURL = playURLFunc(ex)
+	e.PlaySrc = buf.String()
+	return e
+}
+
 func exampleID(id, suffix string) string {
 	switch {
 	case id == "" && suffix == "":
@@ -227,6 +328,37 @@ func exampleID(id, suffix string) string {
 
 // htmlPackage is the template used to render
 // documentation HTML.
+
+// blockNames lists the named sections of htmlPackage that a TemplateSet
+// may override individually. The order is not the order of the rendered
+// page: "codewalks" is rendered right after "overview", and "example" is
+// rendered inside "overview", "funcs", "types", and "methods".
+var blockNames = []string{
+	"overview", "index", "consts", "vars", "funcs", "types", "methods", "notes", "example", "codewalks",
+}
+
+// funcsContext is the argument passed to the "funcs" block: the funcs to
+// render alongside the package's examples, needed to look up each func's
+// runnable examples by name.
+type funcsContext struct {
+	Funcs    []*doc.Func
+	Examples *examples
+}
+
+// typesContext is the argument passed to the "types" block.
+type typesContext struct {
+	Types    []*doc.Type
+	Examples *examples
+}
+
+// methodsContext is the argument passed to the "methods" block, nested
+// inside "types" for a single type's methods.
+type methodsContext struct {
+	TypeName string
+	Methods  []*doc.Func
+	Examples *examples
+}
+
 var htmlPackage = template.Must(template.New("package").Funcs(
 	map[string]interface{}{
 		"ternary": func(q, a, b interface{}) interface{} {
@@ -242,13 +374,25 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 		"render_decl":     (*render.Renderer)(nil).DeclHTML,
 		"render_code":     (*render.Renderer)(nil).CodeHTML,
 		"source_link":     func() string { return "" },
+		"funcs_ctx": func(fs []*doc.Func, exs *examples) funcsContext {
+			return funcsContext{Funcs: fs, Examples: exs}
+		},
+		"types_ctx": func(ts []*doc.Type, exs *examples) typesContext {
+			return typesContext{Types: ts, Examples: exs}
+		},
+		"methods_ctx": func(tname string, ms []*doc.Func, exs *examples) methodsContext {
+			return methodsContext{TypeName: tname, Methods: ms, Examples: exs}
+		},
 	},
 ).Parse(`{{- "" -}}
-{{- if or .Doc .Consts .Vars .Funcs .Types .Examples.List -}}
+{{- if or .Doc .Consts .Vars .Funcs .Types .Examples.List .Codewalks -}}
 	<ul>{{"\n" -}}
 	{{- if or .Doc (index .Examples.Map "") -}}
 		<li><a href="#pkg-overview">Overview</a></li>{{"\n" -}}
 	{{- end -}}
+	{{- if .Codewalks -}}
+		<li><a href="#pkg-codewalks">Codewalks</a></li>{{"\n" -}}
+	{{- end -}}
 	{{- if or .Consts .Vars .Funcs .Types -}}
 		<li><a href="#pkg-index">Index</a></li>{{"\n" -}}
 	{{- end -}}
@@ -259,13 +403,32 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 {{- end -}}
 
 {{- if or .Doc (index .Examples.Map "") -}}
+	{{- template "overview" . -}}
+{{- end -}}
+
+{{- if .Codewalks -}}
+	{{- template "codewalks" . -}}
+{{- end -}}
+
+{{- if or .Consts .Vars .Funcs .Types -}}
+	<h2 id="pkg-index">Index <a href="#pkg-index">¶</a></h2>{{"\n\n" -}}
+	{{- template "index" . -}}
+	{{- template "consts" .Consts -}}
+	{{- template "vars" .Vars -}}
+	{{- template "funcs" (funcs_ctx .Funcs .Examples) -}}
+	{{- template "types" (types_ctx .Types .Examples) -}}
+{{- end -}}
+
+{{/* TODO(b/142795082): finalize URL scheme and design, then factor out inline CSS style */}}
+{{- template "notes" .Notes -}}
+
+{{- define "overview" -}}
 	<h2 id="pkg-overview">Overview <a href="#pkg-overview">¶</a></h2>{{"\n\n" -}}
 	{{render_doc .Doc}}{{"\n" -}}
 	{{- template "example" (index .Examples.Map "") -}}
 {{- end -}}
 
-{{- if or .Consts .Vars .Funcs .Types -}}
-	<h2 id="pkg-index">Index <a href="#pkg-index">¶</a></h2>{{"\n\n" -}}
+{{- define "index" -}}
 	<ul>{{"\n" -}}
 	{{- if .Consts -}}<li><a href="#pkg-constants">Constants</a></li>{{"\n"}}{{- end -}}
 	{{- if .Vars -}}<li><a href="#pkg-variables">Variables</a></li>{{"\n"}}{{- end -}}
@@ -296,23 +459,38 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 		{{- end -}}
 		</ul>{{"\n" -}}
 	{{- end -}}
+{{- end -}}
+
+{{- define "codewalks" -}}
+	<h3 id="pkg-codewalks">Codewalks <a href="#pkg-codewalks">¶</a></h3>{{"\n" -}}
+	<ul>{{"\n" -}}
+	{{- range .Codewalks -}}
+		<li><a href="{{.URL}}">{{.Title}}</a></li>{{"\n" -}}
+	{{- end -}}
+	</ul>{{"\n" -}}
+{{- end -}}
 
-	{{- if .Consts -}}<h3 id="pkg-constants">Constants <a href="#pkg-constants">¶</a></h3>{{"\n"}}{{- end -}}
-	{{- range .Consts -}}
+{{- define "consts" -}}
+	{{- if . -}}<h3 id="pkg-constants">Constants <a href="#pkg-constants">¶</a></h3>{{"\n"}}{{- end -}}
+	{{- range . -}}
 		{{- $out := render_decl .Doc .Decl -}}
 		{{- $out.Decl -}}
 		{{- $out.Doc -}}
 		{{"\n"}}
 	{{- end -}}
+{{- end -}}
 
-	{{- if .Vars -}}<h3 id="pkg-variables">Variables <a href="#pkg-variables">¶</a></h3>{{"\n"}}{{- end -}}
-	{{- range .Vars -}}
+{{- define "vars" -}}
+	{{- if . -}}<h3 id="pkg-variables">Variables <a href="#pkg-variables">¶</a></h3>{{"\n"}}{{- end -}}
+	{{- range . -}}
 		{{- $out := render_decl .Doc .Decl -}}
 		{{- $out.Decl -}}
 		{{- $out.Doc -}}
 		{{"\n"}}
 	{{- end -}}
+{{- end -}}
 
+{{- define "funcs" -}}
 	{{- range .Funcs -}}
 		<h3 id="{{.Name}}">func {{source_link .Name .Decl}} <a href="#{{.Name}}">¶</a></h3>{{"\n"}}
 		{{- $out := render_decl .Doc .Decl -}}
@@ -321,7 +499,9 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 		{{"\n"}}
 		{{- template "example" (index $.Examples.Map .Name) -}}
 	{{- end -}}
+{{- end -}}
 
+{{- define "types" -}}
 	{{- range .Types -}}
 		{{- $tname := .Name -}}
 		<h3 id="{{.Name}}">type {{source_link .Name .Decl}} <a href="#{{.Name}}">¶</a></h3>{{"\n"}}
@@ -345,35 +525,32 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 			{{"\n"}}
 		{{- end -}}
 
-		{{- range .Funcs -}}
-			<h3 id="{{.Name}}">func {{source_link .Name .Decl}} <a href="#{{.Name}}">¶</a></h3>{{"\n"}}
-			{{- $out := render_decl .Doc .Decl -}}
-			{{- $out.Decl -}}
-			{{- $out.Doc -}}
-			{{"\n"}}
-			{{- template "example" (index $.Examples.Map .Name) -}}
-		{{- end -}}
+		{{- template "funcs" (funcs_ctx .Funcs $.Examples) -}}
+		{{- template "methods" (methods_ctx $tname .Methods $.Examples) -}}
+	{{- end -}}
+{{- end -}}
 
-		{{- range .Methods -}}
-			{{- $name := (printf "%s.%s" $tname .Name) -}}
-			<h3 id="{{$name}}">func ({{.Recv}}) {{source_link .Name .Decl}} <a href="#{{$name}}">¶</a></h3>{{"\n"}}
-			{{- $out := render_decl .Doc .Decl -}}
-			{{- $out.Decl -}}
-			{{- $out.Doc -}}
-			{{"\n"}}
-			{{- template "example" (index $.Examples.Map $name) -}}
-		{{- end -}}
+{{- define "methods" -}}
+	{{- range .Methods -}}
+		{{- $name := (printf "%s.%s" $.TypeName .Name) -}}
+		<h3 id="{{$name}}">func ({{.Recv}}) {{source_link .Name .Decl}} <a href="#{{$name}}">¶</a></h3>{{"\n"}}
+		{{- $out := render_decl .Doc .Decl -}}
+		{{- $out.Decl -}}
+		{{- $out.Doc -}}
+		{{"\n"}}
+		{{- template "example" (index $.Examples.Map $name) -}}
 	{{- end -}}
 {{- end -}}
 
-{{/* TODO(b/142795082): finalize URL scheme and design, then factor out inline CSS style */}}
-{{- range $marker, $content := .Notes -}}
+{{- define "notes" -}}
+	{{- range $marker, $content := . -}}
 	<h2 id="pkg-note-{{$marker}}">{{$marker}}s <a href="#pkg-note-{{$marker}}">¶</a></h2>
 	<ul style="padding-left: 20px; list-style: initial;">{{"\n" -}}
 	{{- range $v := $content -}}
 		<li style="margin: 6px 0 6px 0;">{{render_doc $v.Body}}</li>
 	{{- end -}}
 	</ul>{{"\n" -}}
+	{{- end -}}
 {{- end -}}
 
 {{- define "example" -}}
@@ -383,7 +560,20 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 		<div class="example-body">{{"\n" -}}
 			{{- if .Doc -}}{{render_doc .Doc}}{{"\n" -}}{{- end -}}
 			<p>Code:</p>{{"\n" -}}
-			{{render_code .Code}}{{"\n" -}}
+			{{- if .Playable -}}
+				<div class="example-playground" id="{{.ID}}-play" data-play-url="{{.PlayURL}}">{{"\n" -}}
+					<textarea class="example-playground-input" id="{{.ID}}-input" spellcheck="false">{{.PlaySrc}}</textarea>{{"\n" -}}
+					<div class="example-playground-buttons">{{"\n" -}}
+						<button class="example-playground-run">Run</button>{{"\n" -}}
+						<button class="example-playground-fmt">Format</button>{{"\n" -}}
+						<button class="example-playground-share">Share</button>{{"\n" -}}
+					</div>{{"\n" -}}
+					<pre class="example-playground-output" id="{{.ID}}-output"></pre>{{"\n" -}}
+					<input type="text" class="example-playground-share-url" id="{{.ID}}-share" readonly hidden>{{"\n" -}}
+				</div>{{"\n" -}}
+			{{- else -}}
+				{{render_code .Code}}{{"\n" -}}
+			{{- end -}}
 			{{- if (or .Output .EmptyOutput) -}}
 				<p>{{ternary .Unordered "Unordered output:" "Output:"}}</p>{{"\n" -}}
 				<pre>{{"\n"}}{{.Output}}</pre>{{"\n" -}}
@@ -0,0 +1,146 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dochtml
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"text/template"
+
+	"golang.org/x/xerrors"
+)
+
+// RenderMarkdown renders package documentation as CommonMark, from the
+// same Page that RenderHTML uses.
+//
+// If the rendered documentation size exceeds the specified limit, an error
+// with ErrTooLarge in its chain will be returned.
+func (d *docRenderer) RenderMarkdown() ([]byte, error) {
+	buf := &limitBuffer{B: new(bytes.Buffer), Remain: d.opt.Limit}
+	err := template.Must(packageMarkdown.Clone()).Funcs(map[string]interface{}{
+		"render_synopsis": func(decl ast.Decl) string { return textSynopsis(d.fset, decl) },
+		"render_doc":      textDoc,
+		"render_decl":     func(docText string, decl ast.Decl) textOut { return textDeclOut(d.fset, docText, decl) },
+		"render_code":     func(code interface{}) string { return textCode(d.fset, code) },
+	}).Execute(buf, d.page)
+	if buf.Remain < 0 {
+		return nil, xerrors.Errorf("dochtml.RenderMarkdown: %w", ErrTooLarge)
+	} else if err != nil {
+		return nil, fmt.Errorf("dochtml.RenderMarkdown: %v", err)
+	}
+	return buf.B.Bytes(), nil
+}
+
+// packageMarkdown is the template used to render documentation as
+// CommonMark. It mirrors the section structure of htmlPackage (overview,
+// index, constants, vars, funcs, types, methods, notes, examples),
+// fencing declarations and example code as ` + "```go```" + ` blocks.
+var packageMarkdown = template.Must(template.New("packageMarkdown").Funcs(
+	map[string]interface{}{
+		"render_synopsis": (func(ast.Decl) string)(nil),
+		"render_doc":      textDoc,
+		"render_decl":     (func(string, ast.Decl) textOut)(nil),
+		"render_code":     (func(interface{}) string)(nil),
+	},
+).Parse(`{{- if or .Doc (index .Examples.Map "")}}## Overview
+
+{{render_doc .Doc}}
+{{template "example_markdown" (index .Examples.Map "")}}
+{{end -}}
+
+{{- if .Codewalks}}## Codewalks
+
+{{range .Codewalks}}- [{{.Title}}]({{.URL}})
+{{end}}
+{{end -}}
+
+{{- if or .Consts .Vars .Funcs .Types}}## Index
+
+{{if .Consts}}- [Constants](#pkg-constants)
+{{end -}}
+{{if .Vars}}- [Variables](#pkg-variables)
+{{end -}}
+{{range .Funcs}}- [{{render_synopsis .Decl}}](#{{.Name}})
+{{end -}}
+{{range .Types}}{{$tname := .Name}}- [type {{$tname}}](#{{$tname}})
+{{range .Funcs}}  - [{{render_synopsis .Decl}}](#{{.Name}})
+{{end -}}
+{{range .Methods}}  - [{{render_synopsis .Decl}}](#{{$tname}}.{{.Name}})
+{{end -}}
+{{end}}
+{{end -}}
+
+{{- if .Consts}}### Constants <a id="pkg-constants"></a>
+
+{{range .Consts}}{{$out := render_decl .Doc .Decl}}` + "```go\n{{$out.Decl}}\n```" + `
+
+{{$out.Doc}}
+{{end}}{{end -}}
+
+{{- if .Vars}}### Variables <a id="pkg-variables"></a>
+
+{{range .Vars}}{{$out := render_decl .Doc .Decl}}` + "```go\n{{$out.Decl}}\n```" + `
+
+{{$out.Doc}}
+{{end}}{{end -}}
+
+{{- range .Funcs}}### func {{.Name}} <a id="{{.Name}}"></a>
+
+{{$out := render_decl .Doc .Decl}}` + "```go\n{{$out.Decl}}\n```" + `
+
+{{$out.Doc}}
+{{template "example_markdown" (index $.Examples.Map .Name)}}
+{{end -}}
+
+{{- range .Types}}{{$tname := .Name}}### type {{$tname}} <a id="{{$tname}}"></a>
+
+{{$out := render_decl .Doc .Decl}}` + "```go\n{{$out.Decl}}\n```" + `
+
+{{$out.Doc}}
+{{template "example_markdown" (index $.Examples.Map .Name)}}
+{{range .Consts}}{{$out := render_decl .Doc .Decl}}` + "```go\n{{$out.Decl}}\n```" + `
+
+{{$out.Doc}}
+{{end}}
+{{range .Vars}}{{$out := render_decl .Doc .Decl}}` + "```go\n{{$out.Decl}}\n```" + `
+
+{{$out.Doc}}
+{{end}}
+{{range .Funcs}}#### func {{.Name}} <a id="{{.Name}}"></a>
+
+{{$out := render_decl .Doc .Decl}}` + "```go\n{{$out.Decl}}\n```" + `
+
+{{$out.Doc}}
+{{template "example_markdown" (index $.Examples.Map .Name)}}
+{{end}}
+{{range .Methods}}{{$name := (printf "%s.%s" $tname .Name)}}#### func ({{.Recv}}) {{.Name}} <a id="{{$name}}"></a>
+
+{{$out := render_decl .Doc .Decl}}` + "```go\n{{$out.Decl}}\n```" + `
+
+{{$out.Doc}}
+{{template "example_markdown" (index $.Examples.Map $name)}}
+{{end}}
+{{end -}}
+
+{{- range $marker, $content := .Notes}}## {{$marker}}s <a id="pkg-note-{{$marker}}"></a>
+
+{{range $v := $content}}{{render_doc $v.Body}}
+{{end}}
+{{end -}}
+
+{{- define "example_markdown" -}}
+{{range .}}#### Example{{with .Suffix}} ({{.}}){{end}}
+
+` + "```go\n{{render_code .Code}}\n```" + `
+
+{{if or .Output .EmptyOutput}}Output:
+
+` + "```\n{{.Output}}\n```" + `
+
+{{end}}
+{{end}}
+{{- end -}}
+`))
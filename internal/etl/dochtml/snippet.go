@@ -0,0 +1,229 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dochtml
+
+import (
+	"fmt"
+	"go/token"
+	"html/template"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/discovery/internal/etl/internal/doc"
+)
+
+// SnippetOptions configures Snippet.
+type SnippetOptions struct {
+	// Limit caps the number of snippets returned. If zero, a default of
+	// 10 is used.
+	Limit int
+
+	// Context is the number of characters of surrounding text included
+	// on each side of a match found inside a Doc string. If zero, a
+	// default of 40 is used.
+	Context int
+}
+
+// A SnippetMatch is a short HTML fragment excerpted from a package's
+// rendered documentation that matches a search query, along with the
+// anchor link into the full package page (as rendered by Render) that it
+// was extracted from.
+type SnippetMatch struct {
+	// Anchor is the id used by the HTML template for the matched
+	// declaration or note, e.g. "TypeName.Method" or "pkg-note-BUG",
+	// without a leading "#".
+	Anchor string
+
+	// Title is a human-readable label for the match, e.g. "func Foo" or
+	// "type Bar".
+	Title string
+
+	// HTML is the matched excerpt, HTML-escaped with the first occurrence
+	// of the query wrapped in <mark>; later occurrences are not marked.
+	HTML template.HTML
+}
+
+// Snippet returns short HTML fragments excerpted from p's documentation
+// that match query, along with anchors into the page that Render would
+// produce for p. It lets a search result listing render result cards
+// without re-rendering the full package page.
+//
+// Snippet walks p.Consts, p.Vars, p.Funcs, p.Types, their methods, and
+// p.Notes, matching both identifier names and Doc text, in the same order
+// those declarations appear in the rendered page.
+func Snippet(fset *token.FileSet, p *doc.Package, query string, opt SnippetOptions) ([]SnippetMatch, error) {
+	if opt.Limit == 0 {
+		opt.Limit = 10
+	}
+	if opt.Context == 0 {
+		opt.Context = 40
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+
+	var out []SnippetMatch
+	full := func() bool { return len(out) >= opt.Limit }
+
+	add := func(anchor, title, name, docText string) bool {
+		if excerpt, ok := matchExcerpt(name, query, opt.Context); ok {
+			out = append(out, SnippetMatch{Anchor: anchor, Title: title, HTML: excerpt})
+			return full()
+		}
+		if excerpt, ok := matchExcerpt(docText, query, opt.Context); ok {
+			out = append(out, SnippetMatch{Anchor: anchor, Title: title, HTML: excerpt})
+		}
+		return full()
+	}
+
+	if add("pkg-overview", "Overview", p.Name, p.Doc) {
+		return out, nil
+	}
+	for _, c := range p.Consts {
+		if add("pkg-constants", "Constants", strings.Join(c.Names, ", "), c.Doc) {
+			return out, nil
+		}
+	}
+	for _, v := range p.Vars {
+		if add("pkg-variables", "Variables", strings.Join(v.Names, ", "), v.Doc) {
+			return out, nil
+		}
+	}
+	for _, f := range p.Funcs {
+		if add(f.Name, fmt.Sprintf("func %s", f.Name), f.Name, f.Doc) {
+			return out, nil
+		}
+	}
+	for _, t := range p.Types {
+		if add(t.Name, fmt.Sprintf("type %s", t.Name), t.Name, t.Doc) {
+			return out, nil
+		}
+		for _, f := range t.Funcs {
+			if add(f.Name, fmt.Sprintf("func %s", f.Name), f.Name, f.Doc) {
+				return out, nil
+			}
+		}
+		for _, m := range t.Methods {
+			anchor := t.Name + "." + m.Name
+			title := fmt.Sprintf("func (%s) %s", m.Recv, m.Name)
+			if add(anchor, title, m.Name, m.Doc) {
+				return out, nil
+			}
+		}
+	}
+	// The template ranges over p.Notes in sorted key order; do the same
+	// here rather than use Go's random map iteration order.
+	markers := make([]string, 0, len(p.Notes))
+	for marker := range p.Notes {
+		markers = append(markers, marker)
+	}
+	sort.Strings(markers)
+	for _, marker := range markers {
+		notes := p.Notes[marker]
+		for i, n := range notes {
+			anchor := "pkg-note-" + marker
+			title := marker
+			if len(notes) > 1 {
+				title = fmt.Sprintf("%s (%d)", marker, i+1)
+			}
+			if add(anchor, title, "", n.Body) {
+				return out, nil
+			}
+		}
+	}
+	return out, nil
+}
+
+// matchExcerpt reports whether query occurs in text (case-insensitively)
+// and, if so, returns an HTML-escaped excerpt of up to radius characters
+// of context on each side of the first match, with the match itself
+// wrapped in <mark>.
+func matchExcerpt(text, query string, radius int) (template.HTML, bool) {
+	if text == "" || query == "" {
+		return "", false
+	}
+	// Find the match by comparing case-folded runes, but keep the byte
+	// offset of each original rune alongside it: strings.Index on
+	// strings.ToLower(text) doesn't work here, because lowercasing a
+	// rune can change its UTF-8 length (e.g. 'Ⱥ' is 2 bytes, its
+	// lowercase 'ⱥ' is 3), so an index found in the lowercased copy
+	// isn't necessarily a valid byte offset into text.
+	offsets, folded := foldRunes(text)
+	_, foldedQuery := foldRunes(query)
+	m := indexRunes(folded, foldedQuery)
+	if m < 0 {
+		return "", false
+	}
+	matchEndRune := m + len(foldedQuery)
+
+	byteOffset := func(runeIdx int) int {
+		if runeIdx >= len(offsets) {
+			return len(text)
+		}
+		return offsets[runeIdx]
+	}
+
+	startRune := m - radius
+	prefix := ""
+	if startRune <= 0 {
+		startRune = 0
+	} else {
+		prefix = "…"
+	}
+	endRune := matchEndRune + radius
+	suffix := ""
+	if endRune >= len(folded) {
+		endRune = len(folded)
+	} else {
+		suffix = "…"
+	}
+
+	start := byteOffset(startRune)
+	matchStart := byteOffset(m)
+	matchEnd := byteOffset(matchEndRune)
+	end := byteOffset(endRune)
+
+	var buf strings.Builder
+	buf.WriteString(prefix)
+	template.HTMLEscape(&buf, []byte(text[start:matchStart]))
+	buf.WriteString("<mark>")
+	template.HTMLEscape(&buf, []byte(text[matchStart:matchEnd]))
+	buf.WriteString("</mark>")
+	template.HTMLEscape(&buf, []byte(text[matchEnd:end]))
+	buf.WriteString(suffix)
+	return template.HTML(buf.String()), true
+}
+
+// foldRunes returns the case-folded runes of s alongside the byte offset
+// in s that each one starts at, so a match found by comparing folded
+// runes can be mapped back to a byte range in the original s.
+func foldRunes(s string) (offsets []int, folded []rune) {
+	offsets = make([]int, 0, len(s))
+	folded = make([]rune, 0, len(s))
+	for i, r := range s {
+		offsets = append(offsets, i)
+		folded = append(folded, unicode.ToLower(r))
+	}
+	return offsets, folded
+}
+
+// indexRunes returns the index of the first occurrence of sub in s, or -1
+// if sub is empty or does not occur in s.
+func indexRunes(s, sub []rune) int {
+	if len(sub) == 0 || len(sub) > len(s) {
+		return -1
+	}
+outer:
+	for i := 0; i+len(sub) <= len(s); i++ {
+		for j, r := range sub {
+			if s[i+j] != r {
+				continue outer
+			}
+		}
+		return i
+	}
+	return -1
+}
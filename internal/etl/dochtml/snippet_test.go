@@ -0,0 +1,97 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dochtml
+
+import "testing"
+
+func TestMatchExcerpt(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		query     string
+		radius    int
+		wantHTML  string
+		wantFound bool
+	}{
+		{
+			name:      "no match",
+			text:      "the quick brown fox",
+			query:     "dog",
+			radius:    10,
+			wantFound: false,
+		},
+		{
+			name:      "empty text",
+			text:      "",
+			query:     "fox",
+			radius:    10,
+			wantFound: false,
+		},
+		{
+			name:      "empty query",
+			text:      "the quick brown fox",
+			query:     "",
+			radius:    10,
+			wantFound: false,
+		},
+		{
+			name:      "exact match, no truncation",
+			text:      "the quick brown fox",
+			query:     "quick",
+			radius:    20,
+			wantHTML:  "the <mark>quick</mark> brown fox",
+			wantFound: true,
+		},
+		{
+			name:      "case-insensitive match",
+			text:      "The Quick Brown Fox",
+			query:     "quick",
+			radius:    20,
+			wantHTML:  "The <mark>Quick</mark> Brown Fox",
+			wantFound: true,
+		},
+		{
+			name:      "truncated with ellipses on both sides",
+			text:      "one two three four five six seven",
+			query:     "four",
+			radius:    3,
+			wantHTML:  "…ee <mark>four</mark> fi…",
+			wantFound: true,
+		},
+		{
+			name:      "match text is HTML-escaped",
+			text:      "a <b> & c",
+			query:     "b",
+			radius:    10,
+			wantHTML:  "a &lt;<mark>b</mark>&gt; &amp; c",
+			wantFound: true,
+		},
+		{
+			name: "multi-byte case folding doesn't misalign byte offsets",
+			// 'Ⱥ' (U+023A) is 2 bytes in UTF-8; its lowercase 'ⱥ'
+			// (U+2C65) is 3 bytes. Folding must not use the folded
+			// copy's byte offsets against the original text.
+			text:      "xȺy",
+			query:     "ⱥ",
+			radius:    10,
+			wantHTML:  "x<mark>Ⱥ</mark>y",
+			wantFound: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := matchExcerpt(tc.text, tc.query, tc.radius)
+			if ok != tc.wantFound {
+				t.Fatalf("matchExcerpt(%q, %q, %d) found = %v, want %v", tc.text, tc.query, tc.radius, ok, tc.wantFound)
+			}
+			if !ok {
+				return
+			}
+			if string(got) != tc.wantHTML {
+				t.Errorf("matchExcerpt(%q, %q, %d) = %q, want %q", tc.text, tc.query, tc.radius, got, tc.wantHTML)
+			}
+		})
+	}
+}
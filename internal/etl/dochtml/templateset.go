@@ -0,0 +1,82 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dochtml
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+)
+
+// TemplateSet lets an operator override individual named blocks of the
+// default HTML template (see blockNames) without forking this package,
+// in the spirit of Hugo's baseof/lookup rules. It is built from an fs.FS
+// via NewTemplateSet and passed to Render via RenderOptions.TemplateSet.
+//
+// For each block name, lookup precedence is:
+//
+//  1. "package.<name>.html" in the filesystem, if present: the override
+//     body for just that block.
+//  2. "package.html" in the filesystem, if present and it defines
+//     {{define "<name>"}}...{{end}}: a single file that may override
+//     several blocks at once.
+//  3. dochtml's embedded default for that block.
+type TemplateSet struct {
+	shared string            // raw contents of package.html, "" if absent
+	named  map[string]string // block name -> raw contents of package.<name>.html
+}
+
+// NewTemplateSet reads package.html and package.<name>.html (for each
+// name in blockNames) from fsys and returns a TemplateSet built from
+// whichever of those files exist. A nil fsys is equivalent to an empty
+// one: every block falls back to the embedded default.
+func NewTemplateSet(fsys fs.FS) (*TemplateSet, error) {
+	ts := &TemplateSet{named: make(map[string]string)}
+	if fsys == nil {
+		return ts, nil
+	}
+	if b, err := fs.ReadFile(fsys, "package.html"); err == nil {
+		ts.shared = string(b)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("dochtml: reading package.html: %v", err)
+	}
+	for _, name := range blockNames {
+		fname := "package." + name + ".html"
+		b, err := fs.ReadFile(fsys, fname)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("dochtml: reading %s: %v", fname, err)
+		}
+		ts.named[name] = string(b)
+	}
+	return ts, nil
+}
+
+// apply overlays ts's overrides, if any, onto t, which must already
+// define "package" and the blocks in blockNames. It returns t with any
+// overridden blocks redefined in place. A nil ts (the common case, no
+// TemplateSet configured) returns t unchanged.
+func (ts *TemplateSet) apply(t *template.Template) (*template.Template, error) {
+	if ts == nil {
+		return t, nil
+	}
+	if ts.shared != "" {
+		if _, err := t.Parse(ts.shared); err != nil {
+			return nil, fmt.Errorf("dochtml: parsing package.html overrides: %v", err)
+		}
+	}
+	for _, name := range blockNames {
+		src, ok := ts.named[name]
+		if !ok {
+			continue
+		}
+		if _, err := t.New(name).Parse(src); err != nil {
+			return nil, fmt.Errorf("dochtml: parsing package.%s.html: %v", name, err)
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dochtml
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/printer"
+	"go/token"
+	"text/template"
+
+	"golang.org/x/xerrors"
+)
+
+// RenderText renders package documentation as plain text, in the style of
+// `go doc`, from the same Page that RenderHTML uses.
+//
+// If the rendered documentation size exceeds the specified limit, an error
+// with ErrTooLarge in its chain will be returned.
+func (d *docRenderer) RenderText() ([]byte, error) {
+	buf := &limitBuffer{B: new(bytes.Buffer), Remain: d.opt.Limit}
+	err := template.Must(packageText.Clone()).Funcs(map[string]interface{}{
+		"render_synopsis": func(decl ast.Decl) string { return textSynopsis(d.fset, decl) },
+		"render_doc":      textDoc,
+		"render_decl":     func(docText string, decl ast.Decl) textOut { return textDeclOut(d.fset, docText, decl) },
+		"render_code":     func(code interface{}) string { return textCode(d.fset, code) },
+	}).Execute(buf, d.page)
+	if buf.Remain < 0 {
+		return nil, xerrors.Errorf("dochtml.RenderText: %w", ErrTooLarge)
+	} else if err != nil {
+		return nil, fmt.Errorf("dochtml.RenderText: %v", err)
+	}
+	return buf.B.Bytes(), nil
+}
+
+// textOut is the plain-text analog of the {Decl, Doc} pair that the render
+// package's DeclHTML returns for HTML output.
+type textOut struct {
+	Decl string
+	Doc  string
+}
+
+func textDeclOut(fset *token.FileSet, docText string, decl ast.Decl) textOut {
+	return textOut{
+		Decl: textDecl(fset, decl),
+		Doc:  textDoc(docText),
+	}
+}
+
+// textSynopsis returns a one-line summary of decl, truncating at the first
+// newline the way godoc's package index does.
+func textSynopsis(fset *token.FileSet, decl ast.Decl) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, decl)
+	s := buf.String()
+	for i, r := range s {
+		if r == '\n' {
+			return s[:i] + " ..."
+		}
+	}
+	return s
+}
+
+// textDecl renders the full source of decl.
+func textDecl(fset *token.FileSet, decl ast.Decl) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, decl)
+	return buf.String()
+}
+
+// textCode renders an example's code, which may be an ast.Node or a
+// *printer.CommentedNode.
+func textCode(fset *token.FileSet, code interface{}) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, code)
+	return buf.String()
+}
+
+// textDoc formats a doc comment as indented plain text, matching the
+// wrapping `go doc` uses.
+func textDoc(text string) string {
+	var buf bytes.Buffer
+	doc.ToText(&buf, text, "", "\t", 80)
+	return buf.String()
+}
+
+// packageText is the template used to render documentation as plain text.
+// It mirrors the section structure of htmlPackage (overview, constants,
+// vars, funcs, types, methods, notes, examples), except that it has no
+// index section.
+var packageText = template.Must(template.New("packageText").Funcs(
+	map[string]interface{}{
+		"render_synopsis": (func(ast.Decl) string)(nil),
+		"render_doc":      textDoc,
+		"render_decl":     (func(string, ast.Decl) textOut)(nil),
+		"render_code":     (func(interface{}) string)(nil),
+	},
+).Parse(`{{- if or .Doc (index .Examples.Map "") -}}
+{{render_doc .Doc}}
+{{- template "example_text" (index .Examples.Map "") -}}
+{{- end -}}
+
+{{- if .Codewalks}}CODEWALKS
+
+{{range .Codewalks}}{{.Title}}
+    {{.URL}}
+{{end}}{{end -}}
+
+{{- if .Consts}}CONSTANTS
+
+{{range .Consts}}{{$out := render_decl .Doc .Decl}}{{$out.Decl}}
+{{$out.Doc}}
+{{end}}{{end -}}
+
+{{- if .Vars}}VARIABLES
+
+{{range .Vars}}{{$out := render_decl .Doc .Decl}}{{$out.Decl}}
+{{$out.Doc}}
+{{end}}{{end -}}
+
+{{- if .Funcs}}FUNCTIONS
+
+{{range .Funcs}}{{$out := render_decl .Doc .Decl}}{{$out.Decl}}
+{{$out.Doc}}
+{{template "example_text" (index $.Examples.Map .Name)}}
+{{end}}{{end -}}
+
+{{- if .Types}}TYPES
+
+{{range .Types}}{{$tname := .Name}}{{$out := render_decl .Doc .Decl}}{{$out.Decl}}
+{{$out.Doc}}
+{{template "example_text" (index $.Examples.Map .Name)}}
+{{range .Consts}}{{$out := render_decl .Doc .Decl}}{{$out.Decl}}
+{{$out.Doc}}
+{{end}}
+{{range .Vars}}{{$out := render_decl .Doc .Decl}}{{$out.Decl}}
+{{$out.Doc}}
+{{end}}
+{{range .Funcs}}{{$out := render_decl .Doc .Decl}}{{$out.Decl}}
+{{$out.Doc}}
+{{template "example_text" (index $.Examples.Map .Name)}}
+{{end}}
+{{range .Methods}}{{$name := (printf "%s.%s" $tname .Name)}}{{$out := render_decl .Doc .Decl}}{{$out.Decl}}
+{{$out.Doc}}
+{{template "example_text" (index $.Examples.Map $name)}}
+{{end}}
+{{end}}{{end -}}
+
+{{- range $marker, $content := .Notes}}{{$marker}}S
+
+{{range $v := $content}}{{render_doc $v.Body}}
+{{end}}
+{{end -}}
+
+{{- define "example_text" -}}
+{{range .}}Example{{with .Suffix}} ({{.}}){{end}}:
+
+{{render_code .Code}}
+{{if or .Output .EmptyOutput}}Output:
+
+{{.Output}}
+{{end}}
+{{end}}
+{{- end -}}
+`))